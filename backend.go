@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Turn is a single message in a conversation, as kept in a session's History
+// and threaded through to backends. Tokens is an estimate, used for
+// budgeting how many turns fit in a backend's context window.
+type Turn struct {
+	Role      string
+	Content   string
+	Tokens    int
+	Timestamp time.Time
+}
+
+// Backend abstracts the LLM service that takeOver talks to. Implementations
+// handle their own wire protocol (WebSocket, HTTP, ...) and authentication.
+type Backend interface {
+	// Name identifies the backend, e.g. for logging and status display.
+	Name() string
+
+	// Complete sends a system/user prompt plus prior turns and returns the
+	// full model response.
+	Complete(ctx context.Context, systemPrompt, userPrompt string, history []Turn) (string, error)
+
+	// StreamComplete is like Complete but pushes partial output to chunks as
+	// it becomes available, closing the channel when done. Backends that
+	// can't stream natively may emit the whole response as a single chunk.
+	StreamComplete(ctx context.Context, systemPrompt, userPrompt string, history []Turn, chunks chan<- string) error
+}
+
+// BackendConfig holds the per-backend settings sourced from flags/env.
+type BackendConfig struct {
+	Model      string
+	EmbedModel string
+	BaseURL    string
+	APIKey     string
+}
+
+// NewBackend constructs the Backend named by backendName, applying cfg.
+func NewBackend(backendName string, cfg BackendConfig) (Backend, error) {
+	switch backendName {
+	case "ophanim":
+		return NewOphanimBackend(), nil
+	case "openai":
+		return NewOpenAIBackend(cfg), nil
+	case "ollama":
+		return NewOllamaBackend(cfg), nil
+	case "anthropic":
+		return NewAnthropicBackend(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (want ophanim, openai, ollama, or anthropic)", backendName)
+	}
+}
+
+// RAGToggler is implemented by backends that support the legacy RAGMode
+// toggle driven by the `@` glyph. Backends without a notion of RAG simply
+// don't implement it.
+type RAGToggler interface {
+	SetRAGMode(enabled bool)
+}
+
+// Embedder is implemented by backends with an embeddings endpoint, so the
+// local RAG index (see rag.go) can embed both indexed chunks and `@`
+// prompts through the same pluggable backend used for chat completion.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// AnthropicBackend talks to the Anthropic Messages API.
+type AnthropicBackend struct {
+	Model   string
+	BaseURL string
+	APIKey  string
+	client  *http.Client
+}
+
+func NewAnthropicBackend(cfg BackendConfig) *AnthropicBackend {
+	model := cfg.Model
+	if model == "" {
+		model = "claude-3-5-sonnet-20241022"
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	return &AnthropicBackend{
+		Model:   model,
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		client:  &http.Client{},
+	}
+}
+
+func (b *AnthropicBackend) Name() string {
+	return "anthropic"
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicMessagesRequest struct {
+	Model     string             `json:"model"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	MaxTokens int                `json:"max_tokens"`
+}
+
+type anthropicMessagesResponse struct {
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b *AnthropicBackend) buildMessages(userPrompt string, history []Turn) []anthropicMessage {
+	messages := make([]anthropicMessage, 0, len(history)+1)
+	for _, turn := range history {
+		messages = append(messages, anthropicMessage{Role: turn.Role, Content: turn.Content})
+	}
+	messages = append(messages, anthropicMessage{Role: "user", Content: userPrompt})
+	return messages
+}
+
+func (b *AnthropicBackend) Complete(ctx context.Context, systemPrompt, userPrompt string, history []Turn) (string, error) {
+	reqBody, err := json.Marshal(anthropicMessagesRequest{
+		Model:     b.Model,
+		System:    systemPrompt,
+		Messages:  b.buildMessages(userPrompt, history),
+		MaxTokens: 1024,
+	})
+	if err != nil {
+		return "", fmt.Errorf("anthropic: marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.BaseURL+"/v1/messages", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("anthropic: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", b.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("anthropic: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("anthropic: read response: %w", err)
+	}
+
+	var parsed anthropicMessagesResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("anthropic: decode response: %w", err)
+	}
+	if parsed.Error != nil {
+		return "", fmt.Errorf("anthropic: %s", parsed.Error.Message)
+	}
+	if len(parsed.Content) == 0 {
+		return "", fmt.Errorf("anthropic: empty response")
+	}
+	return parsed.Content[0].Text, nil
+}
+
+// StreamComplete does not yet use Anthropic's SSE streaming mode; it runs
+// Complete to finish and emits the result as a single chunk.
+func (b *AnthropicBackend) StreamComplete(ctx context.Context, systemPrompt, userPrompt string, history []Turn, chunks chan<- string) error {
+	defer close(chunks)
+	response, err := b.Complete(ctx, systemPrompt, userPrompt, history)
+	if err != nil {
+		return err
+	}
+	chunks <- response
+	return nil
+}
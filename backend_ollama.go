@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OllamaBackend talks to a local Ollama server's /api/chat endpoint.
+type OllamaBackend struct {
+	Model      string
+	EmbedModel string
+	BaseURL    string
+	client     *http.Client
+}
+
+func NewOllamaBackend(cfg BackendConfig) *OllamaBackend {
+	model := cfg.Model
+	if model == "" {
+		model = "llama3"
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	embedModel := cfg.EmbedModel
+	if embedModel == "" {
+		embedModel = "nomic-embed-text"
+	}
+	return &OllamaBackend{
+		Model:      model,
+		EmbedModel: embedModel,
+		BaseURL:    baseURL,
+		client:     &http.Client{},
+	}
+}
+
+func (b *OllamaBackend) Name() string {
+	return "ollama"
+}
+
+type ollamaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []ollamaChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+}
+
+type ollamaChatResponse struct {
+	Message ollamaChatMessage `json:"message"`
+	Error   string            `json:"error"`
+}
+
+func (b *OllamaBackend) buildMessages(systemPrompt, userPrompt string, history []Turn) []ollamaChatMessage {
+	messages := make([]ollamaChatMessage, 0, len(history)+2)
+	if systemPrompt != "" {
+		messages = append(messages, ollamaChatMessage{Role: "system", Content: systemPrompt})
+	}
+	for _, turn := range history {
+		messages = append(messages, ollamaChatMessage{Role: turn.Role, Content: turn.Content})
+	}
+	messages = append(messages, ollamaChatMessage{Role: "user", Content: userPrompt})
+	return messages
+}
+
+func (b *OllamaBackend) Complete(ctx context.Context, systemPrompt, userPrompt string, history []Turn) (string, error) {
+	reqBody, err := json.Marshal(ollamaChatRequest{
+		Model:    b.Model,
+		Messages: b.buildMessages(systemPrompt, userPrompt, history),
+		Stream:   false,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ollama: marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.BaseURL+"/api/chat", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("ollama: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ollama: read response: %w", err)
+	}
+
+	var parsed ollamaChatResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("ollama: decode response: %w", err)
+	}
+	if parsed.Error != "" {
+		return "", fmt.Errorf("ollama: %s", parsed.Error)
+	}
+	return parsed.Message.Content, nil
+}
+
+// StreamComplete does not yet use Ollama's NDJSON streaming mode; it runs
+// Complete to finish and emits the result as a single chunk.
+func (b *OllamaBackend) StreamComplete(ctx context.Context, systemPrompt, userPrompt string, history []Turn, chunks chan<- string) error {
+	defer close(chunks)
+	response, err := b.Complete(ctx, systemPrompt, userPrompt, history)
+	if err != nil {
+		return err
+	}
+	chunks <- response
+	return nil
+}
+
+type ollamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type ollamaEmbeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+	Error     string    `json:"error"`
+}
+
+// Embed implements Embedder via Ollama's /api/embeddings endpoint.
+func (b *OllamaBackend) Embed(ctx context.Context, text string) ([]float32, error) {
+	reqBody, err := json.Marshal(ollamaEmbeddingRequest{Model: b.EmbedModel, Prompt: text})
+	if err != nil {
+		return nil, fmt.Errorf("ollama: marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.BaseURL+"/api/embeddings", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("ollama: build embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama: embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ollama: read embedding response: %w", err)
+	}
+
+	var parsed ollamaEmbeddingResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("ollama: decode embedding response: %w", err)
+	}
+	if parsed.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", parsed.Error)
+	}
+	if len(parsed.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama: empty embedding response")
+	}
+
+	embedding := make([]float32, len(parsed.Embedding))
+	for i, v := range parsed.Embedding {
+		embedding[i] = float32(v)
+	}
+	return embedding, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// OpenAIBackend talks to an OpenAI-compatible Chat Completions endpoint.
+type OpenAIBackend struct {
+	Model      string
+	EmbedModel string
+	BaseURL    string
+	APIKey     string
+	client     *http.Client
+}
+
+func NewOpenAIBackend(cfg BackendConfig) *OpenAIBackend {
+	model := cfg.Model
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	embedModel := cfg.EmbedModel
+	if embedModel == "" {
+		embedModel = "text-embedding-3-small"
+	}
+	return &OpenAIBackend{
+		Model:      model,
+		EmbedModel: embedModel,
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		client:     &http.Client{},
+	}
+}
+
+func (b *OpenAIBackend) Name() string {
+	return "openai"
+}
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []openAIChatMessage `json:"messages"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIChatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b *OpenAIBackend) buildMessages(systemPrompt, userPrompt string, history []Turn) []openAIChatMessage {
+	messages := make([]openAIChatMessage, 0, len(history)+2)
+	if systemPrompt != "" {
+		messages = append(messages, openAIChatMessage{Role: "system", Content: systemPrompt})
+	}
+	for _, turn := range history {
+		messages = append(messages, openAIChatMessage{Role: turn.Role, Content: turn.Content})
+	}
+	messages = append(messages, openAIChatMessage{Role: "user", Content: userPrompt})
+	return messages
+}
+
+func (b *OpenAIBackend) Complete(ctx context.Context, systemPrompt, userPrompt string, history []Turn) (string, error) {
+	reqBody, err := json.Marshal(openAIChatRequest{
+		Model:    b.Model,
+		Messages: b.buildMessages(systemPrompt, userPrompt, history),
+	})
+	if err != nil {
+		return "", fmt.Errorf("openai: marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.BaseURL+"/chat/completions", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("openai: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+b.APIKey)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("openai: read response: %w", err)
+	}
+
+	var parsed openAIChatResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("openai: decode response: %w", err)
+	}
+	if parsed.Error != nil {
+		return "", fmt.Errorf("openai: %s", parsed.Error.Message)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("openai: empty response")
+	}
+	return parsed.Choices[0].Message.Content, nil
+}
+
+// StreamComplete does not yet use OpenAI's SSE streaming mode; it runs
+// Complete to finish and emits the result as a single chunk.
+func (b *OpenAIBackend) StreamComplete(ctx context.Context, systemPrompt, userPrompt string, history []Turn, chunks chan<- string) error {
+	defer close(chunks)
+	response, err := b.Complete(ctx, systemPrompt, userPrompt, history)
+	if err != nil {
+		return err
+	}
+	chunks <- response
+	return nil
+}
+
+type openAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Embed implements Embedder via OpenAI's /embeddings endpoint.
+func (b *OpenAIBackend) Embed(ctx context.Context, text string) ([]float32, error) {
+	reqBody, err := json.Marshal(openAIEmbeddingRequest{Model: b.EmbedModel, Input: text})
+	if err != nil {
+		return nil, fmt.Errorf("openai: marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.BaseURL+"/embeddings", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("openai: build embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+b.APIKey)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai: embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: read embedding response: %w", err)
+	}
+
+	var parsed openAIEmbeddingResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("openai: decode embedding response: %w", err)
+	}
+	if parsed.Error != nil {
+		return nil, fmt.Errorf("openai: %s", parsed.Error.Message)
+	}
+	if len(parsed.Data) == 0 {
+		return nil, fmt.Errorf("openai: empty embedding response")
+	}
+	return parsed.Data[0].Embedding, nil
+}
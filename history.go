@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// historySchemaVersion is bumped whenever the on-disk .soul format changes,
+// so LoadHistory knows when it needs to migrate an older file.
+const historySchemaVersion = 1
+
+// defaultMaxContextTokens is used when --max-context-tokens is unset or zero.
+const defaultMaxContextTokens = 4096
+
+// History is the versioned, typed record of a session's conversation,
+// persisted to ~/.config/ophanim/<session>.soul.
+type History struct {
+	Version int    `json:"version"`
+	Turns   []Turn `json:"turns"`
+}
+
+// NewHistory returns an empty, current-schema History.
+func NewHistory() *History {
+	return &History{Version: historySchemaVersion}
+}
+
+// NewTurn builds a Turn with its token estimate and timestamp filled in.
+func NewTurn(role, content string) Turn {
+	return Turn{
+		Role:      role,
+		Content:   content,
+		Tokens:    estimateTokens(content),
+		Timestamp: time.Now(),
+	}
+}
+
+// estimateTokens is a cheap stand-in for a real BPE tokenizer: ~4 characters
+// per token, which is close enough for context-budget purposes.
+func estimateTokens(s string) int {
+	if len(s) == 0 {
+		return 0
+	}
+	return (len(s) + 3) / 4
+}
+
+// Append records a turn.
+func (h *History) Append(turn Turn) {
+	h.Turns = append(h.Turns, turn)
+}
+
+// Budget trims h.Turns in place to the most recent turns whose cumulative
+// token estimate fits within maxTokens, dropping older turns off the front,
+// and returns the resulting slice. If summarize is non-nil, the dropped
+// turns are folded into a single leading turn via summarize instead of
+// being discarded outright. Mutating h.Turns (rather than just returning a
+// derived slice) keeps the persisted history itself bounded, and means a
+// later call only has to summarize whatever has overflowed since the last
+// trim, not the same growing tail every time. maxTokens <= 0 means no
+// limit.
+func (h *History) Budget(maxTokens int, summarize func(dropped []Turn) (string, error)) []Turn {
+	if maxTokens <= 0 || len(h.Turns) == 0 {
+		return h.Turns
+	}
+
+	total := 0
+	cut := 0
+	for i := len(h.Turns) - 1; i >= 0; i-- {
+		total += h.Turns[i].Tokens
+		if total > maxTokens {
+			cut = i + 1
+			break
+		}
+	}
+	if cut == 0 {
+		return h.Turns
+	}
+
+	dropped, kept := h.Turns[:cut], h.Turns[cut:]
+	if summarize == nil {
+		h.Turns = kept
+		return h.Turns
+	}
+	summary, err := summarize(dropped)
+	if err != nil || summary == "" {
+		h.Turns = kept
+		return h.Turns
+	}
+	summaryTurn := NewTurn("system", fmt.Sprintf("Summary of earlier conversation: %s", summary))
+	h.Turns = append([]Turn{summaryTurn}, kept...)
+	return h.Turns
+}
+
+// historyFilePath returns the .soul path for a tmux session name.
+func historyFilePath(sessionName string) string {
+	return filepath.Join(fmt.Sprintf("%s/.config/ophanim", os.Getenv("HOME")), sessionName+".soul")
+}
+
+// LoadHistory reads a session's .soul file, migrating it from the legacy
+// Ophanim gabs schema if needed. A missing file is not an error - it just
+// means a fresh History.
+func LoadHistory(sessionName string) (*History, error) {
+	data, err := os.ReadFile(historyFilePath(sessionName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewHistory(), nil
+		}
+		return nil, fmt.Errorf("history: read %s: %w", sessionName, err)
+	}
+	return migrateHistory(data)
+}
+
+// migrateHistory parses data as the current schema, falling back to the
+// legacy {"output":{"data":[[prompt,reply],...]}} gabs format written by
+// earlier versions of OphanimClient.
+func migrateHistory(data []byte) (*History, error) {
+	var h History
+	if err := json.Unmarshal(data, &h); err == nil && h.Version == historySchemaVersion {
+		return &h, nil
+	}
+
+	legacy, err := gabs.ParseJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("history: unrecognized .soul format: %w", err)
+	}
+
+	migrated := NewHistory()
+	if legacy.ExistsP("output.data") {
+		for _, exchange := range legacy.Path("output.data").Children() {
+			pair := exchange.Children()
+			if len(pair) < 2 {
+				continue
+			}
+			prompt, _ := pair[0].Data().(string)
+			reply, _ := pair[1].Data().(string)
+			migrated.Append(NewTurn("user", prompt))
+			migrated.Append(NewTurn("assistant", reply))
+		}
+	}
+	return migrated, nil
+}
+
+// Save writes the History to sessionName's .soul file.
+func (h *History) Save(sessionName string) error {
+	path := historyFilePath(sessionName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("history: create config dir: %w", err)
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("history: marshal: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("history: write %s: %w", sessionName, err)
+	}
+	return nil
+}
+
+// DeleteHistoryFile removes a session's .soul file, if present.
+func DeleteHistoryFile(sessionName string) error {
+	err := os.Remove(historyFilePath(sessionName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("history: delete %s: %w", sessionName, err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"flag"
@@ -10,18 +11,27 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/Jeffail/gabs/v2"
-	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 	"github.com/rivo/tview"
 )
 
+// sessionContext is the per-tmux-session state that used to live globally on
+// Muxnet: its own History and its own Backend instance (so RAGMode and any
+// backend-side session identity, e.g. OphanimBackend.SessionHash, don't leak
+// between tmux sessions). mu serializes access so concurrent pane scans
+// can't race on the same session's History.
+type sessionContext struct {
+	mu      sync.Mutex
+	history *History
+	backend Backend
+}
+
 type Muxnet struct {
 	sessionName           string
 	responseDelay         time.Duration
@@ -35,32 +45,51 @@ type Muxnet struct {
 	logger                *log.Logger
 	mu                    sync.Mutex
 	watchedSessions       map[string]bool
-	ophanim               *OphanimClient
-}
-
-type OphanimClient struct {
-	SessionHash     string
-	ModelConnection *websocket.Conn
-	SessionHistory  *gabs.Container
-	RAGMode         bool
-	RAGQuery        string
-	RAGSource       string
-	SaveDir         string
+	promptsMu             sync.Mutex
+	backendName           string
+	backendConfig         BackendConfig
+	autoExec              bool
+	reviewTimeout         time.Duration
+	maxContextTokens      int
+	sessions              map[string]*sessionContext
+	sessionsMu            sync.Mutex
+	sandbox               *Sandbox
+	dryRun                bool
+	poll                  bool
+	panesMu               sync.Mutex
+	paneSessions          map[string]string
+	controlClientsMu      sync.Mutex
+	controlClients        map[string]bool
+	ragIndex              *RAGIndex
+	ragTopK               int
 }
 
-func NewMuxnet(sessionName string, responseDelay time.Duration, daemonMode bool) *Muxnet {
+func NewMuxnet(sessionName string, responseDelay time.Duration, daemonMode bool, backendName string, backendConfig BackendConfig, autoExec bool, reviewTimeout time.Duration, maxContextTokens int, sandbox *Sandbox, dryRun bool, poll bool, ragIndex *RAGIndex, ragTopK int) *Muxnet {
 	logger := log.New(os.Stdout, "MuxNet: ", log.Ldate|log.Ltime|log.Lshortfile)
+
 	m := &Muxnet{
 		sessionName:           sessionName,
 		responseDelay:         responseDelay,
-		promptPattern:         regexp.MustCompile(`.*#([$@%!])\s*(.+?)\s*\.`),
+		promptPattern:         regexp.MustCompile(`.*#([$@%!^])\s*(.+?)\s*\.`),
 		processedPrompts:      make(map[string]map[string]time.Time),
 		deduplicationInterval: 60 * time.Second,
 		sessionStatus:         make(map[string]string),
 		daemonMode:            daemonMode,
 		logger:                logger,
 		watchedSessions:       make(map[string]bool),
-		ophanim:               NewOphanimClient(),
+		backendName:           backendName,
+		backendConfig:         backendConfig,
+		autoExec:              autoExec,
+		reviewTimeout:         reviewTimeout,
+		maxContextTokens:      maxContextTokens,
+		sessions:              make(map[string]*sessionContext),
+		sandbox:               sandbox,
+		dryRun:                dryRun,
+		poll:                  poll,
+		paneSessions:          make(map[string]string),
+		controlClients:        make(map[string]bool),
+		ragIndex:              ragIndex,
+		ragTopK:               ragTopK,
 	}
 
 	if !daemonMode {
@@ -71,15 +100,75 @@ func NewMuxnet(sessionName string, responseDelay time.Duration, daemonMode bool)
 	return m
 }
 
-func NewOphanimClient() *OphanimClient {
-	return &OphanimClient{
-		SessionHash:    uuid.New().String()[:11],
-		SessionHistory: gabs.New(),
-		RAGMode:        false,
-		RAGQuery:       "Current Events",
-		RAGSource:      "Google",
-		SaveDir:        fmt.Sprintf("%s/.config/ophanim/", os.Getenv("HOME")),
+// getOrCreateSession returns the sessionContext for a tmux session, lazily
+// loading its persisted History and spinning up a fresh Backend instance the
+// first time that session is seen.
+func (m *Muxnet) getOrCreateSession(sessionName string) (*sessionContext, error) {
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
+	if ctx, ok := m.sessions[sessionName]; ok {
+		return ctx, nil
+	}
+
+	history, err := LoadHistory(sessionName)
+	if err != nil {
+		m.logger.Printf("Error loading history for session %s, starting fresh: %v", sessionName, err)
+		history = NewHistory()
+	}
+
+	backend, err := NewBackend(m.backendName, m.backendConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &sessionContext{history: history, backend: backend}
+	m.sessions[sessionName] = ctx
+	return ctx, nil
+}
+
+// forkSession clones sourceName's History into a brand new context under
+// targetName, served by its own Backend instance. It's how the `^` glyph
+// branches a conversation.
+func (m *Muxnet) forkSession(sourceName, targetName string) error {
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
+	source, ok := m.sessions[sourceName]
+	if !ok {
+		return fmt.Errorf("no active context for session %s", sourceName)
+	}
+
+	source.mu.Lock()
+	clonedTurns := make([]Turn, len(source.history.Turns))
+	copy(clonedTurns, source.history.Turns)
+	source.mu.Unlock()
+
+	forked := &History{Version: historySchemaVersion, Turns: clonedTurns}
+	if err := forked.Save(targetName); err != nil {
+		return err
+	}
+
+	backend, err := NewBackend(m.backendName, m.backendConfig)
+	if err != nil {
+		return err
+	}
+
+	m.sessions[targetName] = &sessionContext{history: forked, backend: backend}
+	return nil
+}
+
+// sessionContextSummary renders a short "(turns: N, ~T tokens)" suffix for
+// the status panel.
+func sessionContextSummary(ctx *sessionContext) string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	tokens := 0
+	for _, turn := range ctx.history.Turns {
+		tokens += turn.Tokens
 	}
+	return fmt.Sprintf(" (turns: %d, ~%d tokens)", len(ctx.history.Turns), tokens)
 }
 
 func (m *Muxnet) updateDisplay() {
@@ -107,7 +196,25 @@ func (m *Muxnet) updateDisplay() {
 	}
 }
 
+// scanSessions is the watch loop's entry point. Control mode (tmux -C
+// attach) is the default: it pushes %output notifications the instant a
+// pane changes instead of re-capturing every session on a timer. --poll
+// falls back to the old busy-poll for environments where control mode
+// isn't available (e.g. tmux < 3.2, or a tmux socket control mode can't
+// attach to).
 func (m *Muxnet) scanSessions() {
+	if m.poll {
+		m.pollSessions()
+		return
+	}
+
+	if err := m.watchControlMode(); err != nil {
+		m.logger.Printf("Control mode watcher failed, falling back to polling: %v", err)
+		m.pollSessions()
+	}
+}
+
+func (m *Muxnet) pollSessions() {
 	for {
 		sessions, err := m.listTmuxSessions()
 		if err != nil {
@@ -130,6 +237,191 @@ func (m *Muxnet) scanSessions() {
 	}
 }
 
+// watchControlMode keeps one long-lived tmux control-mode client attached
+// per tmux session, and reacts to their combined notification stream
+// instead of polling. A bare "tmux -C attach" (no -t) lands on a single
+// most-recently-used session, and tmux only pushes %output for panes of
+// that one session - verified empirically, a second live session goes
+// completely dark. Since there's no server-wide subscription exposed by
+// control mode, covering every session means attaching to every session.
+//
+// New sessions created after the initial attach are picked up by a cheap
+// session-list poll (no pane capture, just names) on m.responseDelay;
+// actual output detection for already-attached sessions stays fully
+// event-driven. It returns an error only once every session's client has
+// exited, so the caller can fall back to pollSessions.
+func (m *Muxnet) watchControlMode() error {
+	sessions, err := m.listTmuxSessions()
+	if err != nil {
+		return fmt.Errorf("control mode: list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("control mode: no tmux sessions to watch")
+	}
+
+	m.refreshPaneTopology()
+	m.sessionStatus = make(map[string]string)
+	for _, session := range sessions {
+		m.monitorSession(session, m.sessionStatus)
+	}
+	m.updateDisplay()
+
+	exited := make(chan string)
+	m.controlClientsMu.Lock()
+	for _, session := range sessions {
+		m.controlClients[session] = true
+		go m.runControlClient(session, exited)
+	}
+	m.controlClientsMu.Unlock()
+
+	ticker := time.NewTicker(m.responseDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case session := <-exited:
+			m.controlClientsMu.Lock()
+			delete(m.controlClients, session)
+			remaining := len(m.controlClients)
+			m.controlClientsMu.Unlock()
+			m.refreshPaneTopology()
+			m.updateDisplay()
+			if remaining == 0 {
+				return fmt.Errorf("control mode: all session clients exited")
+			}
+
+		case <-ticker.C:
+			m.syncControlClients(exited)
+		}
+	}
+}
+
+// syncControlClients starts a control-mode client for any tmux session that
+// doesn't already have one running, so sessions created after the initial
+// attach get picked up. Sessions that closed are reaped by
+// runControlClient reporting on exited, not from here.
+func (m *Muxnet) syncControlClients(exited chan<- string) {
+	sessions, err := m.listTmuxSessions()
+	if err != nil {
+		m.logger.Printf("Control mode: error listing sessions: %v", err)
+		return
+	}
+
+	m.controlClientsMu.Lock()
+	defer m.controlClientsMu.Unlock()
+	for _, session := range sessions {
+		if m.controlClients[session] {
+			continue
+		}
+		m.controlClients[session] = true
+		go m.runControlClient(session, exited)
+	}
+}
+
+// runControlClient attaches a tmux control-mode client to session and feeds
+// its notification stream to handleControlModeLine until the client exits
+// (session closed, tmux gone, attach rejected, ...), then reports session
+// on exited so the supervisor loop can drop it from the live set.
+func (m *Muxnet) runControlClient(session string, exited chan<- string) {
+	defer func() { exited <- session }()
+
+	cmd := exec.Command("tmux", "-C", "attach", "-t", session)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		m.logger.Printf("Control mode: stdout pipe for session %s: %v", session, err)
+		return
+	}
+
+	// tmux control mode also reads commands from stdin; leaving it unset
+	// defaults to /dev/null, which reads as immediate EOF and makes tmux
+	// detach the client right after attaching. Hold stdin open on a pipe we
+	// never write to or close until the client exits, so tmux keeps the
+	// control session alive for notifications.
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		m.logger.Printf("Control mode: stdin pipe for session %s: %v", session, err)
+		return
+	}
+	defer stdinWriter.Close()
+	cmd.Stdin = stdinReader
+
+	if err := cmd.Start(); err != nil {
+		m.logger.Printf("Control mode: attach to session %s: %v", session, err)
+		stdinReader.Close()
+		return
+	}
+	stdinReader.Close()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m.handleControlModeLine(scanner.Text())
+	}
+
+	if err := cmd.Wait(); err != nil {
+		m.logger.Printf("Control mode: session %s client exited: %v", session, err)
+	}
+}
+
+// handleControlModeLine parses one line of tmux control-mode output and
+// reacts to the notifications scanSessions cares about. Other notification
+// types (%layout-change, %extended-output, sixel, ...) are ignored.
+func (m *Muxnet) handleControlModeLine(line string) {
+	switch {
+	case strings.HasPrefix(line, "%output "):
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return
+		}
+		paneID := fields[1]
+		m.panesMu.Lock()
+		sessionName, known := m.paneSessions[paneID]
+		m.panesMu.Unlock()
+		if !known {
+			return
+		}
+		newStatus := make(map[string]string)
+		m.monitorSession(sessionName, newStatus)
+		m.mu.Lock()
+		for k, v := range newStatus {
+			m.sessionStatus[k] = v
+		}
+		m.mu.Unlock()
+		m.updateDisplay()
+
+	case strings.HasPrefix(line, "%window-add "), strings.HasPrefix(line, "%session-changed "):
+		m.refreshPaneTopology()
+
+	case strings.HasPrefix(line, "%session-closed "):
+		m.refreshPaneTopology()
+		m.updateDisplay()
+	}
+}
+
+// refreshPaneTopology re-lists every pane on the server and rebuilds the
+// pane-id -> session-name lookup that %output notifications are keyed by.
+func (m *Muxnet) refreshPaneTopology() {
+	cmd := exec.Command("tmux", "list-panes", "-a", "-F", "#{pane_id} #{session_name}")
+	output, err := cmd.Output()
+	if err != nil {
+		m.logger.Printf("Error listing panes: %v", err)
+		return
+	}
+
+	panes := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		panes[fields[0]] = fields[1]
+	}
+
+	m.panesMu.Lock()
+	m.paneSessions = panes
+	m.panesMu.Unlock()
+}
+
 func (m *Muxnet) listTmuxSessions() ([]string, error) {
 	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}")
 	output, err := cmd.Output()
@@ -146,7 +438,9 @@ func (m *Muxnet) listTmuxSessions() ([]string, error) {
 
 func (m *Muxnet) monitorSession(sessionName string, newStatus map[string]string) {
 	m.setSessionLabel(sessionName, "👁️ ")
+	m.promptsMu.Lock()
 	m.watchedSessions[sessionName] = true
+	m.promptsMu.Unlock()
 
 	content, err := m.capturePane(sessionName)
 	if err != nil {
@@ -167,23 +461,42 @@ func (m *Muxnet) monitorSession(sessionName string, newStatus map[string]string)
 	glyph, prompt := match[1], match[2]
 	currentTime := time.Now()
 
+	ctx, err := m.getOrCreateSession(sessionName)
+	if err != nil {
+		m.logger.Printf("Error initializing context for session %s: %v", sessionName, err)
+		return
+	}
+
 	if glyph == "!" {
-		m.ophanim.DeleteSessionFile(m.sessionName)
-		newStatus[sessionName] = "Session file deleted"
+		ctx.mu.Lock()
+		ctx.history = NewHistory()
+		ctx.mu.Unlock()
+		if err := DeleteHistoryFile(sessionName); err != nil {
+			m.logger.Printf("Error deleting session file: %v", err)
+		}
+		newStatus[sessionName] = "Session file deleted" + sessionContextSummary(ctx)
+	} else if glyph == "^" {
+		targetName := prompt
+		if err := m.forkSession(sessionName, targetName); err != nil {
+			m.logger.Printf("Error forking session %s into %s: %v", sessionName, targetName, err)
+			newStatus[sessionName] = fmt.Sprintf("[Fork failed] %v", err)
+		} else {
+			newStatus[sessionName] = fmt.Sprintf("Forked into %s", targetName)
+		}
 	} else if m.canExecutePrompt(sessionName, prompt, currentTime) {
-		newStatus[sessionName] = prompt
 		useRAG := glyph == "@"
 		useScreenContent := glyph == "%"
+		var screenContent string
 		if useScreenContent {
-			screenContent := m.getFilteredScreenContent(content)
-			m.takeOver(sessionName, prompt, useRAG, screenContent)
-		} else {
-			m.takeOver(sessionName, prompt, useRAG, "")
+			screenContent = m.getFilteredScreenContent(content)
 		}
+		newStatus[sessionName] = m.takeOver(sessionName, ctx, prompt, useRAG, screenContent) + sessionContextSummary(ctx)
+		m.promptsMu.Lock()
 		if m.processedPrompts[sessionName] == nil {
 			m.processedPrompts[sessionName] = make(map[string]time.Time)
 		}
 		m.processedPrompts[sessionName][prompt] = currentTime
+		m.promptsMu.Unlock()
 	} else {
 		newStatus[sessionName] = fmt.Sprintf("[Skipped] %s", prompt)
 	}
@@ -223,7 +536,7 @@ func (m *Muxnet) getFilteredScreenContent(content string) string {
 	scanner := bufio.NewScanner(strings.NewReader(content))
 	for scanner.Scan() {
 		line := scanner.Text()
-		if !strings.Contains(line, "#$") && !strings.Contains(line, "#@") && !strings.Contains(line, "#%") && !strings.Contains(line, "#!") {
+		if !strings.Contains(line, "#$") && !strings.Contains(line, "#@") && !strings.Contains(line, "#%") && !strings.Contains(line, "#!") && !strings.Contains(line, "#^") {
 			filtered = append(filtered, line)
 		}
 	}
@@ -231,6 +544,9 @@ func (m *Muxnet) getFilteredScreenContent(content string) string {
 }
 
 func (m *Muxnet) canExecutePrompt(sessionName, prompt string, currentTime time.Time) bool {
+	m.promptsMu.Lock()
+	defer m.promptsMu.Unlock()
+
 	if m.processedPrompts[sessionName] == nil {
 		return true
 	}
@@ -241,7 +557,12 @@ func (m *Muxnet) canExecutePrompt(sessionName, prompt string, currentTime time.T
 	return currentTime.Sub(lastExecutionTime) > m.deduplicationInterval
 }
 
-func (m *Muxnet) takeOver(sessionName, prompt string, useRAG bool, screenContent string) {
+// takeOver drives a single prompt through to completion and returns a short
+// status string describing the outcome for the tview panel.
+func (m *Muxnet) takeOver(sessionName string, ctx *sessionContext, prompt string, useRAG bool, screenContent string) string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
 	m.showProcessingMessage(sessionName)
 
 	var fullPrompt string
@@ -252,17 +573,127 @@ func (m *Muxnet) takeOver(sessionName, prompt string, useRAG bool, screenContent
 	}
 
 	systemPrompt := "System Prompt: Provide the system commands necessary to achieve the user's goal stated below. Assume the user is on linux and provide ONLY the commands.\n NO explanations.\nNo sudo\n."
-	fullPrompt = fmt.Sprintf("%s%s\n\n'''bash\n", systemPrompt, fullPrompt)
+	fullPrompt = fmt.Sprintf("%s\n\n'''bash\n", fullPrompt)
+
+	if toggler, ok := ctx.backend.(RAGToggler); ok {
+		toggler.SetRAGMode(useRAG)
+	}
+	if useRAG {
+		if contextBlock, err := m.augmentWithRAG(ctx.backend, prompt); err != nil {
+			m.logger.Printf("RAG retrieval skipped for session %s: %v", sessionName, err)
+		} else if contextBlock != "" {
+			systemPrompt = contextBlock + systemPrompt
+		}
+	}
+
+	contextTurns := ctx.history.Budget(m.maxContextTokens, func(dropped []Turn) (string, error) {
+		return m.summarizeDroppedTurns(ctx.backend, dropped)
+	})
+
+	chunks := make(chan string)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- ctx.backend.StreamComplete(context.Background(), systemPrompt, fullPrompt, contextTurns, chunks)
+	}()
+
+	streamFile, closePopup := m.openStreamPopup(sessionName)
+	var response strings.Builder
+	for chunk := range chunks {
+		response.WriteString(chunk)
+		if streamFile != nil {
+			streamFile.WriteString(chunk)
+			streamFile.Sync()
+		}
+	}
+	closePopup()
+
+	if err := <-streamErr; err != nil {
+		m.logger.Printf("Error completing prompt via %s backend: %v", ctx.backend.Name(), err)
+		m.clearProcessingMessage(sessionName)
+		return fmt.Sprintf("[Error] %v", err)
+	}
 
-	m.ophanim.RAGMode = useRAG
-	response := m.ophanim.PromptChatbot(fullPrompt, false)
+	ctx.history.Append(NewTurn("user", prompt))
+	ctx.history.Append(NewTurn("assistant", response.String()))
+	if err := ctx.history.Save(sessionName); err != nil {
+		m.logger.Printf("Error saving history for session %s: %v", sessionName, err)
+	}
+
+	filteredResponse := m.filterCommandResponse(response.String())
+
+	if m.dryRun {
+		m.logger.Printf("[dry-run] session %s staged commands:\n%s", sessionName, filteredResponse)
+		if verdict := m.sandbox.InspectLines(filteredResponse); verdict != nil {
+			m.logger.Printf("[dry-run] session %s sandbox would block: %s", sessionName, verdict.Reason)
+		}
+		m.clearProcessingMessage(sessionName)
+		return fmt.Sprintf("[Dry-run] %s", prompt)
+	}
 
-	filteredResponse := m.filterCommandResponse(response)
+	if verdict := m.sandbox.InspectLines(filteredResponse); verdict != nil {
+		m.logger.Printf("Refusing to send commands for session %s: %s", sessionName, verdict.Reason)
+		m.clearProcessingMessage(sessionName)
+		return fmt.Sprintf("[Blocked] %s", verdict.Reason)
+	}
 
 	m.sendResponseToPane(sessionName, "\x03") // Send Ctrl+C
-	m.sendResponseToPane(sessionName, filteredResponse)
+	m.stageResponseToPane(sessionName, filteredResponse)
+
+	if m.autoExec {
+		go m.confirmAfterTimeout(sessionName)
+	}
 
 	m.clearProcessingMessage(sessionName)
+	return prompt
+}
+
+// summarizeDroppedTurns asks backend to condense turns that no longer fit in
+// the context budget into a short summary, preserved as a single leading
+// turn instead of being silently discarded.
+func (m *Muxnet) summarizeDroppedTurns(backend Backend, dropped []Turn) (string, error) {
+	var transcript strings.Builder
+	for _, turn := range dropped {
+		fmt.Fprintf(&transcript, "%s: %s\n", turn.Role, turn.Content)
+	}
+
+	summarizePrompt := "Summarize the conversation below concisely, preserving any facts or decisions that matter for future turns."
+	return backend.Complete(context.Background(), summarizePrompt, transcript.String(), nil)
+}
+
+// openStreamPopup opens a tmux display-popup over sessionName that tails a
+// scratch file, and returns that file (for writing streamed chunks into) plus
+// a cleanup func that must be called once streaming finishes. streamFile is
+// nil if the popup/file could not be set up, in which case chunks are simply
+// dropped and the caller should still drain the channel.
+func (m *Muxnet) openStreamPopup(sessionName string) (*os.File, func()) {
+	streamPath := filepath.Join(os.TempDir(), fmt.Sprintf("muxnet-%s.stream", sessionName))
+	f, err := os.Create(streamPath)
+	if err != nil {
+		m.logger.Printf("Error creating stream file for session %s: %v", sessionName, err)
+		return nil, func() {}
+	}
+
+	popup := exec.Command("tmux", "display-popup", "-t", sessionName, "-E", fmt.Sprintf("tail -n +1 -f %s", streamPath))
+	if err := popup.Start(); err != nil {
+		m.logger.Printf("Error opening popup for session %s: %v", sessionName, err)
+	}
+
+	return f, func() {
+		f.Close()
+		os.Remove(streamPath)
+		if popup.Process != nil {
+			popup.Process.Kill()
+			go popup.Wait()
+		}
+	}
+}
+
+// confirmAfterTimeout auto-presses Enter on a staged command after
+// reviewTimeout, giving the user a window to edit or cancel it first.
+func (m *Muxnet) confirmAfterTimeout(sessionName string) {
+	time.Sleep(m.reviewTimeout)
+	m.logger.Printf("Review timeout elapsed, executing staged commands for session %s", sessionName)
+	m.confirmPane(sessionName)
 }
 
 func (m *Muxnet) filterCommandResponse(response string) string {
@@ -290,166 +721,32 @@ func (m *Muxnet) sendResponseToPane(sessionName, response string) {
 	exec.Command("tmux", "send-keys", "-t", sessionName, response, "Enter").Run()
 }
 
-func (m *Muxnet) cleanup() {
-	for sessionName := range m.watchedSessions {
-		m.setSessionLabel(sessionName, "")
-	}
-}
-
-func (o *OphanimClient) initWSClient() {
-	server := LookupEnvOrString("OPHANIM_HOST", "ophanim.azai.run")
-	port := LookupEnvOrString("OPHANIM_PORT", "443")
-	proto := LookupEnvOrString("OPHANIM_PROTO", "wss")
-
-	var err error
-	o.ModelConnection, _, err = websocket.DefaultDialer.Dial(fmt.Sprintf("%s://%s:%s/queue/join", proto, server, port), nil)
-	if err != nil {
-		log.Fatal("Failed to connect to WebSocket server:", err)
-	}
-
-	_, message, err := o.ModelConnection.ReadMessage()
-	if err != nil {
-		return
-	}
-	if !strings.Contains(string(message), `"msg":"send_hash"`) {
-		log.Fatal("Unexpected message from server:", string(message))
-	}
-
-	initialMsg := []byte(fmt.Sprintf(`{"fn_index": 4,"session_hash":"%s"}`, o.SessionHash))
-	if err := o.ModelConnection.WriteMessage(websocket.TextMessage, initialMsg); err != nil {
-		log.Fatal("Failed to send initial message:", err)
-	}
-}
-
-func (o *OphanimClient) PromptChatbot(userInput string, hasChatbotSession bool) (modelResponse string) {
-	o.initWSClient()
-	defer o.ModelConnection.Close()
-
-	for {
-		_, message, err := o.ModelConnection.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
-				return modelResponse
-			} else {
-				log.Fatal("Failed to read message from server:", err)
-				return modelResponse
-			}
-		}
-
-		if strings.Contains(string(message), `"msg":"send_data"`) {
-			nextMessage := o.constructClientMessage(userInput, hasChatbotSession)
-			if nextMessage != "" {
-				if err := o.ModelConnection.WriteMessage(websocket.TextMessage, []byte(nextMessage)); err != nil {
-					log.Fatal("Failed to send message to server:", err)
-				}
-			}
-		}
-
-		if strings.Contains(string(message), `"msg":"process_starts"`) {
-			continue
-		}
-
-		if strings.Contains(string(message), `"msg":"process_generating"`) {
-			continue
-		}
-
-		if strings.Contains(string(message), `"msg":"process_completed"`) {
-			parsedMessage, err := gabs.ParseJSON([]byte(message))
-			if err != nil {
-				log.Fatal("Failed to parse message from server:", err)
-			}
-			if parsedMessage.ExistsP("output.data") {
-				lastEntry := len(parsedMessage.Path("output.data.0").Children()) - 1
-				o.SessionHistory.ArrayAppendP(parsedMessage.Path(fmt.Sprintf("output.data.0.%d", lastEntry)).Children(), "output.data")
-				modelResponse = parsedMessage.Path(fmt.Sprintf("output.data.0.%d", lastEntry)).Children()[1].Data().(string)
-			} else {
-				modelResponse = "No response from server"
-				log.Println("No response from server")
-			}
-			return modelResponse
-		}
-	}
-}
-func (o *OphanimClient) constructClientMessage(userInput string, isContinuation bool) string {
-	userInput = strings.ReplaceAll(userInput, "\n", "")
-	userInput = strings.ReplaceAll(userInput, "\r", "")
-	userInput = strings.ReplaceAll(userInput, "\x00", "")
-	userInput = strings.ReplaceAll(userInput, "\x1a", "")
-	userInput = strings.ReplaceAll(userInput, "'", "")
-	userInput = strings.ReplaceAll(userInput, `"`, "")
-
-	var RAG string
-	if o.RAGMode {
-		RAG = "true"
-	} else {
-		RAG = "false"
-	}
-
-	if !isContinuation {
-		return fmt.Sprintf(`{"data":["","%s","%s",null,[["%s",""]],%s],"event_data":null,"fn_index":6,"session_hash":"%s"}`, o.RAGQuery, o.RAGSource, strings.TrimSpace(userInput), RAG, o.SessionHash)
-	} else {
-		if o.SessionHistory.ExistsP("output.data") {
-			lastMessage := o.SessionHistory.Path("output.data").String()
-			lastMessage = lastMessage[1 : len(lastMessage)-1]
-			return fmt.Sprintf(`{"data":["","%s","%s",null,[%s,["%s",""]],%s],"event_data":null,"fn_index":6,"session_hash":"%s"}`, o.RAGQuery, o.RAGSource, lastMessage, strings.TrimSpace(userInput), RAG, o.SessionHash)
-		}
-		return ""
-	}
-}
-
-func (o *OphanimClient) SaveChatHistory(fileName string) {
-	chatHistoryFile := fmt.Sprintf("%s/%s.soul", o.SaveDir, fileName)
-	if err := os.WriteFile(chatHistoryFile, []byte(o.SessionHistory.String()), 0644); err != nil {
-		log.Printf("Failed to save chat history to file: %v", err)
-		return
-	}
+// stageResponseToPane types response at the target pane's prompt without
+// pressing Enter, so the user can review or edit it before running it.
+func (m *Muxnet) stageResponseToPane(sessionName, response string) {
+	exec.Command("tmux", "send-keys", "-t", sessionName, response).Run()
 }
 
-func (o *OphanimClient) LoadChatHistory(fileName string) {
-	chatHistoryFile := fmt.Sprintf("%s/%s.soul", o.SaveDir, fileName)
-	chatHistory, err := os.ReadFile(chatHistoryFile)
-	if err != nil {
-		log.Printf("Failed to load chat history from file: %v", err)
-		return
-	}
-	tempHistory, _ := gabs.ParseJSON(chatHistory)
-	o.SessionHistory = tempHistory
+// confirmPane presses Enter on whatever is currently staged at the pane's prompt.
+func (m *Muxnet) confirmPane(sessionName string) {
+	exec.Command("tmux", "send-keys", "-t", sessionName, "Enter").Run()
 }
 
-func (o *OphanimClient) UndoLastInteraction() {
-	if o.SessionHistory.ExistsP("output.data") {
-		numberOfExchanges := len(o.SessionHistory.Path("output.data").Children())
-		if numberOfExchanges > 1 {
-			lastEntry := numberOfExchanges - 1
-			o.SessionHistory.DeleteP(fmt.Sprintf("output.data.%d", lastEntry))
-		}
+func (m *Muxnet) cleanup() {
+	m.promptsMu.Lock()
+	sessionNames := make([]string, 0, len(m.watchedSessions))
+	for sessionName := range m.watchedSessions {
+		sessionNames = append(sessionNames, sessionName)
 	}
-}
+	m.promptsMu.Unlock()
 
-func (o *OphanimClient) ListChatHistory() {
-	files, err := os.ReadDir(o.SaveDir)
-	if err != nil {
-		log.Printf("Failed to list chat history files: %v", err)
-		return
-	}
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), ".soul") {
-			fmt.Println(strings.TrimSuffix(file.Name(), ".soul"))
-		}
+	for _, sessionName := range sessionNames {
+		m.setSessionLabel(sessionName, "")
 	}
-}
-
-func (o *OphanimClient) DeleteSessionFile(sessionName string) {
-	filePath := fmt.Sprintf("%s/%s.soul", o.SaveDir, sessionName)
-	err := os.Remove(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("Session file %s does not exist.", filePath)
-		} else {
-			log.Printf("Error deleting session file: %v", err)
+	if m.ragIndex != nil {
+		if err := m.ragIndex.Close(); err != nil {
+			m.logger.Printf("Error closing RAG index: %v", err)
 		}
-	} else {
-		log.Printf("Session file %s deleted successfully.", filePath)
 	}
 }
 
@@ -466,9 +763,26 @@ func generateSessionName() string {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "index" {
+		if err := runIndexCommand(os.Args[2:]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
 	sessionFlag := flag.String("session", "", "Specify a custom session name")
 	delayFlag := flag.Float64("delay", 2, "Specify the response delay in seconds")
 	daemonFlag := flag.Bool("d", false, "Run in daemon mode")
+	backendFlag := flag.String("backend", LookupEnvOrString("MUXNET_BACKEND", "ophanim"), "LLM backend to use: ophanim, openai, ollama, or anthropic (env MUXNET_BACKEND)")
+	modelFlag := flag.String("model", "", "Model name to request from the backend (backend-specific default if unset)")
+	baseURLFlag := flag.String("base-url", "", "Override the backend's API base URL")
+	apiKeyFlag := flag.String("api-key", "", "API key for the backend (defaults to the backend's standard env var)")
+	autoExecFlag := flag.Bool("auto-exec", true, "Automatically press Enter on staged commands after the review timeout; if false, commands are staged only and must be run manually")
+	reviewTimeoutFlag := flag.Duration("review-timeout", 5*time.Second, "How long to wait after staging commands before auto-exec presses Enter")
+	maxContextTokensFlag := flag.Int("max-context-tokens", defaultMaxContextTokens, "Token budget for conversation history kept in context")
+	dryRunFlag := flag.Bool("dry-run", false, "Inspect staged commands and log the sandbox verdict, but never send them to the pane")
+	pollFlag := flag.Bool("poll", false, "Busy-poll capture-pane on an interval instead of watching a tmux control-mode event stream")
+	ragTopKFlag := flag.Int("rag-top-k", defaultRAGTopK, "Number of indexed chunks to retrieve per @ prompt")
 	flag.Parse()
 
 	sessionName := *sessionFlag
@@ -476,7 +790,26 @@ func main() {
 		sessionName = generateSessionName()
 	}
 
-	muxnet := NewMuxnet(sessionName, time.Duration(*delayFlag)*time.Second, *daemonFlag)
+	backendConfig := BackendConfig{
+		Model:   *modelFlag,
+		BaseURL: *baseURLFlag,
+		APIKey:  *apiKeyFlag,
+	}
+	if _, err := NewBackend(*backendFlag, backendConfig); err != nil {
+		log.Fatalf("Failed to initialize backend: %v", err)
+	}
+
+	sandbox, err := NewSandbox()
+	if err != nil {
+		log.Fatalf("Failed to initialize sandbox: %v", err)
+	}
+
+	ragIndex, err := OpenRAGIndex()
+	if err != nil {
+		log.Printf("Warning: RAG index unavailable, @ prompts will skip retrieval: %v", err)
+	}
+
+	muxnet := NewMuxnet(sessionName, time.Duration(*delayFlag)*time.Second, *daemonFlag, *backendFlag, backendConfig, *autoExecFlag, *reviewTimeoutFlag, *maxContextTokensFlag, sandbox, *dryRunFlag, *pollFlag, ragIndex, *ragTopKFlag)
 
 	// Set up signal handling
 	sigChan := make(chan os.Signal, 1)
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// OphanimBackend talks to the hosted Ophanim Gradio-style WebSocket endpoint
+// using its fn_index-based JSON protocol.
+type OphanimBackend struct {
+	SessionHash     string
+	ModelConnection *websocket.Conn
+	RAGMode         bool
+	RAGQuery        string
+	RAGSource       string
+}
+
+// NewOphanimBackend creates a fresh client with a new session hash.
+func NewOphanimBackend() *OphanimBackend {
+	return &OphanimBackend{
+		SessionHash: uuid.New().String()[:11],
+		RAGMode:     false,
+		RAGQuery:    "Current Events",
+		RAGSource:   "Google",
+	}
+}
+
+func (o *OphanimBackend) Name() string {
+	return "ophanim"
+}
+
+func (o *OphanimBackend) SetRAGMode(enabled bool) {
+	o.RAGMode = enabled
+}
+
+// Complete implements Backend. The Ophanim protocol has no separate system
+// prompt, so systemPrompt is folded into userPrompt; history supplies the
+// prior exchanges the protocol needs to replay for continuation.
+func (o *OphanimBackend) Complete(ctx context.Context, systemPrompt, userPrompt string, history []Turn) (string, error) {
+	return o.PromptChatbot(systemPrompt+userPrompt, history), nil
+}
+
+// StreamComplete has no real incremental delivery over this protocol, so it
+// runs Complete to finish and emits the result as a single chunk.
+func (o *OphanimBackend) StreamComplete(ctx context.Context, systemPrompt, userPrompt string, history []Turn, chunks chan<- string) error {
+	defer close(chunks)
+	response, err := o.Complete(ctx, systemPrompt, userPrompt, history)
+	if err != nil {
+		return err
+	}
+	chunks <- response
+	return nil
+}
+
+func (o *OphanimBackend) initWSClient() {
+	server := LookupEnvOrString("OPHANIM_HOST", "ophanim.azai.run")
+	port := LookupEnvOrString("OPHANIM_PORT", "443")
+	proto := LookupEnvOrString("OPHANIM_PROTO", "wss")
+
+	var err error
+	o.ModelConnection, _, err = websocket.DefaultDialer.Dial(fmt.Sprintf("%s://%s:%s/queue/join", proto, server, port), nil)
+	if err != nil {
+		log.Fatal("Failed to connect to WebSocket server:", err)
+	}
+
+	_, message, err := o.ModelConnection.ReadMessage()
+	if err != nil {
+		return
+	}
+	if !strings.Contains(string(message), `"msg":"send_hash"`) {
+		log.Fatal("Unexpected message from server:", string(message))
+	}
+
+	initialMsg := []byte(fmt.Sprintf(`{"fn_index": 4,"session_hash":"%s"}`, o.SessionHash))
+	if err := o.ModelConnection.WriteMessage(websocket.TextMessage, initialMsg); err != nil {
+		log.Fatal("Failed to send initial message:", err)
+	}
+}
+
+func (o *OphanimBackend) PromptChatbot(userInput string, history []Turn) (modelResponse string) {
+	o.initWSClient()
+	defer o.ModelConnection.Close()
+
+	for {
+		_, message, err := o.ModelConnection.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
+				return modelResponse
+			} else {
+				log.Fatal("Failed to read message from server:", err)
+				return modelResponse
+			}
+		}
+
+		if strings.Contains(string(message), `"msg":"send_data"`) {
+			nextMessage := o.constructClientMessage(userInput, history)
+			if nextMessage != "" {
+				if err := o.ModelConnection.WriteMessage(websocket.TextMessage, []byte(nextMessage)); err != nil {
+					log.Fatal("Failed to send message to server:", err)
+				}
+			}
+		}
+
+		if strings.Contains(string(message), `"msg":"process_starts"`) {
+			continue
+		}
+
+		if strings.Contains(string(message), `"msg":"process_generating"`) {
+			continue
+		}
+
+		if strings.Contains(string(message), `"msg":"process_completed"`) {
+			var parsed struct {
+				Output struct {
+					Data [][]interface{} `json:"data"`
+				} `json:"output"`
+			}
+			if err := json.Unmarshal(message, &parsed); err != nil {
+				log.Fatal("Failed to parse message from server:", err)
+			}
+			if len(parsed.Output.Data) > 0 {
+				lastEntry := parsed.Output.Data[len(parsed.Output.Data)-1]
+				if len(lastEntry) > 1 {
+					modelResponse, _ = lastEntry[1].(string)
+				}
+			} else {
+				modelResponse = "No response from server"
+				log.Println("No response from server")
+			}
+			return modelResponse
+		}
+	}
+}
+
+// constructClientMessage builds the fn_index 6 payload. The "data" field is
+// ["", ragQuery, ragSource, null, exchanges, ragEnabled], where exchanges is
+// the full list of [prompt, reply] pairs: prior turns from history plus the
+// current prompt paired with an empty reply for the server to fill in.
+func (o *OphanimBackend) constructClientMessage(userInput string, history []Turn) string {
+	userInput = strings.ReplaceAll(userInput, "\n", "")
+	userInput = strings.ReplaceAll(userInput, "\r", "")
+	userInput = strings.ReplaceAll(userInput, "\x00", "")
+	userInput = strings.ReplaceAll(userInput, "\x1a", "")
+
+	exchanges := append(historyToExchanges(history), [2]string{strings.TrimSpace(userInput), ""})
+	exchangesJSON, err := json.Marshal(exchanges)
+	if err != nil {
+		log.Printf("Failed to marshal exchange history: %v", err)
+		return ""
+	}
+
+	ragQuery, err := json.Marshal(o.RAGQuery)
+	if err != nil {
+		log.Printf("Failed to marshal RAG query: %v", err)
+		return ""
+	}
+	ragSource, err := json.Marshal(o.RAGSource)
+	if err != nil {
+		log.Printf("Failed to marshal RAG source: %v", err)
+		return ""
+	}
+
+	return fmt.Sprintf(`{"data":["",%s,%s,null,%s,%t],"event_data":null,"fn_index":6,"session_hash":"%s"}`,
+		ragQuery, ragSource, exchangesJSON, o.RAGMode, o.SessionHash)
+}
+
+// historyToExchanges pairs up alternating user/assistant turns into the
+// [prompt, reply] shape the Ophanim protocol expects.
+func historyToExchanges(history []Turn) [][2]string {
+	exchanges := make([][2]string, 0, len(history)/2)
+	for i := 0; i+1 < len(history); i += 2 {
+		exchanges = append(exchanges, [2]string{history[i].Content, history[i+1].Content})
+	}
+	return exchanges
+}
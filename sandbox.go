@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Verdict is the result of inspecting a single staged command line.
+type Verdict struct {
+	Allowed bool
+	Reason  string
+}
+
+// rawPolicy is the YAML shape of ~/.config/muxnet/policy.yaml.
+type rawPolicy struct {
+	Denylist  []string `yaml:"denylist"`
+	Allowlist []string `yaml:"allowlist"`
+}
+
+// Policy holds the compiled denylist/allowlist patterns, checked against
+// each invoked command's rendered "binary arg1 arg2..." form.
+type Policy struct {
+	Denylist  []*regexp.Regexp
+	Allowlist []*regexp.Regexp
+}
+
+func defaultRawPolicy() rawPolicy {
+	return rawPolicy{
+		Denylist: []string{
+			`:\s*\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`,
+			`\bmkfs(\.\w+)?\b`,
+			`\bdd\b.*\bof=/dev/`,
+			`\b(curl|wget)\b.*\|\s*(sh|bash|zsh)\b`,
+			`^\s*sudo\b`,
+		},
+	}
+}
+
+// policyFilePath is the fixed location documented for muxnet's sandbox policy.
+func policyFilePath() string {
+	return filepath.Join(fmt.Sprintf("%s/.config/muxnet", os.Getenv("HOME")), "policy.yaml")
+}
+
+func loadPolicy() (Policy, error) {
+	raw := defaultRawPolicy()
+
+	data, err := os.ReadFile(policyFilePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return Policy{}, fmt.Errorf("sandbox: read policy: %w", err)
+		}
+	} else {
+		var parsed rawPolicy
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return Policy{}, fmt.Errorf("sandbox: parse policy: %w", err)
+		}
+		if len(parsed.Denylist) > 0 {
+			raw.Denylist = parsed.Denylist
+		}
+		raw.Allowlist = parsed.Allowlist
+	}
+
+	return compilePolicy(raw)
+}
+
+func compilePolicy(raw rawPolicy) (Policy, error) {
+	var policy Policy
+	for _, pattern := range raw.Denylist {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Policy{}, fmt.Errorf("sandbox: invalid denylist pattern %q: %w", pattern, err)
+		}
+		policy.Denylist = append(policy.Denylist, re)
+	}
+	for _, pattern := range raw.Allowlist {
+		// Allowlist patterns grant a bypass of every other check below, so
+		// (unlike the denylist) they're anchored to the whole line: an
+		// unanchored substring match would let a narrow allowlist entry
+		// wave through an entire compound line, e.g. "ls" allowlisted would
+		// also match "ls && rm -rf /".
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return Policy{}, fmt.Errorf("sandbox: invalid allowlist pattern %q: %w", pattern, err)
+		}
+		policy.Allowlist = append(policy.Allowlist, re)
+	}
+	return policy, nil
+}
+
+// InvokedCommand is one command extracted from a line's shell AST.
+type InvokedCommand struct {
+	Binary string
+	Args   []string
+}
+
+func (c InvokedCommand) String() string {
+	return strings.Join(append([]string{c.Binary}, c.Args...), " ")
+}
+
+// shellInterpreters are binaries that execute a string argument as its own
+// shell script rather than treating it as an opaque argument, the way every
+// other command does. A denylisted command wrapped as sh -c "rm -rf /" (or
+// bash -c/zsh -c/eval) parses as a single, innocuous-looking CallExpr whose
+// Binary is "sh" - extractInvokedCommands has to recurse into these or the
+// AST walk is trivially bypassed by one level of quoting.
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "ksh": true, "dash": true,
+}
+
+// extractInvokedCommands parses line as shell syntax and walks the AST for
+// every simple command it invokes, so checks operate on actual invocations
+// rather than pattern-matching raw, possibly-quoted text. It also recurses
+// into the script argument of sh -c/bash -c/.../eval invocations, since
+// those execute their argument as shell code rather than consuming it as
+// plain data.
+func extractInvokedCommands(line string) ([]InvokedCommand, error) {
+	parser := syntax.NewParser(syntax.Variant(syntax.LangBash))
+	file, err := parser.Parse(strings.NewReader(line), "")
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	var commands []InvokedCommand
+	var walkErr error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if walkErr != nil {
+			return false
+		}
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		words := make([]string, len(call.Args))
+		for i, word := range call.Args {
+			words[i] = wordString(word)
+		}
+		cmd := InvokedCommand{Binary: words[0], Args: words[1:]}
+		commands = append(commands, cmd)
+
+		nested, err := nestedScript(call)
+		if err != nil {
+			walkErr = fmt.Errorf("parse nested script for %q: %w", cmd.String(), err)
+			return false
+		}
+		if nested != "" {
+			nestedCommands, err := extractInvokedCommands(nested)
+			if err != nil {
+				walkErr = fmt.Errorf("parse nested script for %q: %w", cmd.String(), err)
+				return false
+			}
+			commands = append(commands, nestedCommands...)
+		}
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return commands, nil
+}
+
+// nestedScript returns the shell script embedded in call, if call is a
+// shell -c invocation or an eval, so the caller can recurse into it. It
+// returns "" for calls that don't embed a script. A script argument built
+// from anything other than literal text (parameter expansion, command
+// substitution, ...) can't be safely extracted ahead of time and is
+// reported via err, since silently skipping it would reopen the same
+// bypass this function exists to close.
+func nestedScript(call *syntax.CallExpr) (string, error) {
+	if len(call.Args) == 0 {
+		return "", nil
+	}
+	binary := wordString(call.Args[0])
+
+	if binary == "eval" {
+		parts := make([]string, 0, len(call.Args)-1)
+		for _, word := range call.Args[1:] {
+			lit, ok := literalWordValue(word)
+			if !ok {
+				return "", fmt.Errorf("eval argument is not a literal string")
+			}
+			parts = append(parts, lit)
+		}
+		return strings.Join(parts, " "), nil
+	}
+
+	if !shellInterpreters[binary] {
+		return "", nil
+	}
+	for i, word := range call.Args {
+		if wordString(word) != "-c" || i+1 >= len(call.Args) {
+			continue
+		}
+		lit, ok := literalWordValue(call.Args[i+1])
+		if !ok {
+			return "", fmt.Errorf("%s -c argument is not a literal string", binary)
+		}
+		return lit, nil
+	}
+	return "", nil
+}
+
+// literalWordValue returns w's value when it's built entirely out of
+// literal text (plain or single/double quoted), and false if it contains
+// anything dynamic (parameter expansion, command substitution, ...) whose
+// value can't be known without actually running the shell.
+func literalWordValue(w *syntax.Word) (string, bool) {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range p.Parts {
+				lit, ok := inner.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+func wordString(w *syntax.Word) string {
+	var sb strings.Builder
+	syntax.NewPrinter().Print(&sb, w)
+	return sb.String()
+}
+
+// isDestructiveRm reports whether cmd is an rm invocation carrying both a
+// recursive and a force flag plus at least one target, regardless of how
+// the flags are split across args (-rf, -fr, -r -f, --recursive --force,
+// mixed with unrelated short flags like -rfv, ...). This operates on
+// cmd.Args directly rather than re-rendering the command to a string and
+// regexing it, because a rendered-string regex is exactly as easy to dodge
+// by splitting flags as the raw shell text it replaced.
+func isDestructiveRm(cmd InvokedCommand) bool {
+	if cmd.Binary != "rm" {
+		return false
+	}
+
+	var recursive, force, hasTarget bool
+	for _, arg := range cmd.Args {
+		switch {
+		case arg == "--":
+			continue
+		case arg == "--recursive":
+			recursive = true
+		case arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "--"):
+			// Some other long flag; not a target.
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for _, c := range arg[1:] {
+				switch c {
+				case 'r', 'R':
+					recursive = true
+				case 'f':
+					force = true
+				}
+			}
+		default:
+			hasTarget = true
+		}
+	}
+	return recursive && force && hasTarget
+}
+
+// Sandbox classifies staged command lines before they're allowed to reach a
+// tmux pane.
+type Sandbox struct {
+	policy        Policy
+	useShellcheck bool
+}
+
+// NewSandbox loads the policy file (or the built-in defaults) and checks
+// whether shellcheck is available on PATH.
+func NewSandbox() (*Sandbox, error) {
+	policy, err := loadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	_, shellcheckErr := exec.LookPath("shellcheck")
+	return &Sandbox{policy: policy, useShellcheck: shellcheckErr == nil}, nil
+}
+
+// Inspect classifies a single line.
+func (s *Sandbox) Inspect(line string) Verdict {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return Verdict{Allowed: true}
+	}
+
+	for _, allow := range s.policy.Allowlist {
+		if allow.MatchString(trimmed) {
+			return Verdict{Allowed: true}
+		}
+	}
+
+	commands, err := extractInvokedCommands(trimmed)
+	if err != nil {
+		return Verdict{Allowed: false, Reason: fmt.Sprintf("could not parse as shell: %v", err)}
+	}
+
+	for _, cmd := range commands {
+		if isDestructiveRm(cmd) {
+			return Verdict{Allowed: false, Reason: fmt.Sprintf("recursive, forced rm: %s", cmd.String())}
+		}
+
+		rendered := cmd.String()
+		for _, deny := range s.policy.Denylist {
+			if deny.MatchString(rendered) || deny.MatchString(trimmed) {
+				return Verdict{Allowed: false, Reason: fmt.Sprintf("matches denylist pattern %q: %s", deny.String(), rendered)}
+			}
+		}
+	}
+
+	if s.useShellcheck {
+		if warning, flagged := s.shellcheckWarning(trimmed); flagged {
+			return Verdict{Allowed: false, Reason: warning}
+		}
+	}
+
+	return Verdict{Allowed: true}
+}
+
+// InspectLines runs Inspect over every non-blank line in response and
+// returns the first blocking Verdict, or nil if everything's allowed.
+func (s *Sandbox) InspectLines(response string) *Verdict {
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if verdict := s.Inspect(line); !verdict.Allowed {
+			return &verdict
+		}
+	}
+	return nil
+}
+
+// shellcheckWarning pipes line through shellcheck, if available, and reports
+// its output as a refusal reason. shellcheck exits non-zero when it flags
+// anything at or above its default severity.
+func (s *Sandbox) shellcheckWarning(line string) (string, bool) {
+	cmd := exec.Command("shellcheck", "-s", "bash", "-")
+	cmd.Stdin = strings.NewReader(line)
+	output, err := cmd.Output()
+	if err == nil || len(output) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("shellcheck flagged this command:\n%s", strings.TrimSpace(string(output))), true
+}
